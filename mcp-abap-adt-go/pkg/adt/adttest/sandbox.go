@@ -0,0 +1,354 @@
+// Package adttest provides a managed sandbox of SAP objects for pkg/adt
+// integration tests, so each test can create programs, classes and tables
+// without hand-rolling lock/unlock/delete plumbing, and without leaking
+// objects into $TMP when a test fails partway through.
+package adttest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"mcp-abap-adt-go/pkg/adt"
+)
+
+// DefaultPackage is the local package new sandbox objects are created in
+// when Sandbox.PackageName is left empty. Local packages never require a
+// transport, which keeps sandbox objects disposable.
+const DefaultPackage = "$TMP"
+
+// Sandbox creates and owns a set of uniquely named SAP objects for the
+// lifetime of a single test. Every object it creates is registered for
+// cleanup via t.Cleanup, which Go's testing package runs in LIFO order, so
+// objects are torn down in the reverse order they were created.
+type Sandbox struct {
+	t           *testing.T
+	client      *adt.Client
+	PackageName string
+
+	prefix string
+	seq    int
+
+	objects []*objectHandle
+}
+
+// NewSandbox returns a Sandbox that creates objects named
+// "ZMCP_<uid><NN><LOCAL>" in PackageName ($TMP by default), where <uid> is
+// a short random suffix so parallel test runs never collide and <NN> is a
+// two-digit sequence number counting the sandbox's objects.
+func NewSandbox(t *testing.T, client *adt.Client) *Sandbox {
+	t.Helper()
+	return &Sandbox{
+		t:           t,
+		client:      client,
+		PackageName: DefaultPackage,
+		prefix:      "ZMCP_" + shortUID(),
+	}
+}
+
+func shortUID() string {
+	var b [3]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "000000"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// name builds the final SAP object name for a local, test-readable
+// identifier such as "MAIN" or "HELPER", truncating to the 30-character
+// limit ADT object names share across program/class/table.
+func (sb *Sandbox) name(local string) string {
+	sb.seq++
+	name := fmt.Sprintf("%s%02d%s", sb.prefix, sb.seq, strings.ToUpper(local))
+	if len(name) > 30 {
+		name = name[:30]
+	}
+	return name
+}
+
+// objectHandle is the state shared by every object kind the sandbox can
+// create: its identity, how to read/write its source, and the lock it
+// currently holds (if any).
+type objectHandle struct {
+	sb         *Sandbox
+	objectType adt.ObjectType
+	name       string
+	objectURL  string
+	sourceURL  string
+	lockHandle string
+}
+
+// Name is the object's final, unique SAP name.
+func (h *objectHandle) Name() string { return h.name }
+
+// ObjectURL is the object's ADT object URL, as used by LockObject,
+// Activate and DeleteObject.
+func (h *objectHandle) ObjectURL() string { return h.objectURL }
+
+func (sb *Sandbox) createObject(objType adt.ObjectType, local, description string) *objectHandle {
+	sb.t.Helper()
+	name := sb.name(local)
+	objectURL := adt.GetObjectURL(objType, name, "")
+	sourceURL := adt.GetSourceURL(objType, name, "")
+
+	err := sb.client.CreateObject(context.Background(), adt.CreateObjectOptions{
+		ObjectType:  objType,
+		Name:        name,
+		Description: description,
+		PackageName: sb.PackageName,
+	})
+	if err != nil {
+		sb.t.Fatalf("adttest: create %s: %v", name, err)
+	}
+
+	h := &objectHandle{sb: sb, objectType: objType, name: name, objectURL: objectURL, sourceURL: sourceURL}
+	sb.objects = append(sb.objects, h)
+	sb.t.Cleanup(h.delete)
+	return h
+}
+
+// lock acquires a MODIFY lock on h, failing the test on error, and
+// remembers the handle so unlock can release it even if the caller's own
+// assertions fail first.
+func (h *objectHandle) lock() string {
+	h.sb.t.Helper()
+	lock, err := h.sb.client.LockObject(context.Background(), h.objectURL, "MODIFY")
+	if err != nil {
+		h.sb.t.Fatalf("adttest: lock %s: %v", h.name, err)
+	}
+	h.lockHandle = lock.LockHandle
+	return lock.LockHandle
+}
+
+func (h *objectHandle) unlock() {
+	h.sb.t.Helper()
+	if h.lockHandle == "" {
+		return
+	}
+	if err := h.sb.client.UnlockObject(context.Background(), h.objectURL, h.lockHandle); err != nil {
+		h.sb.t.Errorf("adttest: unlock %s: %v", h.name, err)
+	}
+	h.lockHandle = ""
+}
+
+func (h *objectHandle) writeSource(src string) {
+	h.sb.t.Helper()
+	lockHandle := h.lock()
+	defer h.unlock()
+	if err := h.sb.client.UpdateSource(context.Background(), h.sourceURL, src, lockHandle, ""); err != nil {
+		h.sb.t.Fatalf("adttest: update source of %s: %v", h.name, err)
+	}
+}
+
+// statusCoder is implemented by adt errors that carry the HTTP status SAP
+// responded with.
+type statusCoder interface{ StatusCode() int }
+
+// isNotFoundErr reports whether err represents a 404: the object genuinely
+// no longer exists, as opposed to a transient failure, a permission error,
+// or a lock held by someone else, any of which should fail the test rather
+// than be silently swallowed as "already deleted".
+func isNotFoundErr(err error) bool {
+	var sc statusCoder
+	return errors.As(err, &sc) && sc.StatusCode() == http.StatusNotFound
+}
+
+// delete is the t.Cleanup callback: it locks and deletes h, tolerating an
+// object that is already gone (e.g. because the test deleted it itself)
+// but failing the test for any other lock/delete error, so a leaked
+// ZMCP_* object is never just a log line.
+func (h *objectHandle) delete() {
+	t := h.sb.t
+	lock, err := h.sb.client.LockObject(context.Background(), h.objectURL, "MODIFY")
+	if err != nil {
+		if isNotFoundErr(err) {
+			t.Logf("adttest: cleanup lock on %s: object already deleted: %v", h.name, err)
+			return
+		}
+		t.Errorf("adttest: cleanup lock on %s failed, %s may have leaked: %v", h.name, h.name, err)
+		return
+	}
+	if err := h.sb.client.DeleteObject(context.Background(), h.objectURL, lock.LockHandle, ""); err != nil {
+		t.Errorf("adttest: cleanup delete of %s: %v", h.name, err)
+		if unlockErr := h.sb.client.UnlockObject(context.Background(), h.objectURL, lock.LockHandle); unlockErr != nil {
+			t.Errorf("adttest: cleanup unlock of %s: %v", h.name, unlockErr)
+		}
+	}
+}
+
+// ProgramHandle is a sandboxed ABAP report/program.
+type ProgramHandle struct{ *objectHandle }
+
+// ClassHandle is a sandboxed ABAP global class.
+type ClassHandle struct{ *objectHandle }
+
+// TableHandle is a sandboxed DDIC table.
+type TableHandle struct{ *objectHandle }
+
+// CreateProgram creates a program named "ZMCP_<uid><NN><LOCAL>" with the given
+// source and registers its cleanup. The test fails immediately if
+// creation or the initial source write fails.
+func (sb *Sandbox) CreateProgram(local, src string) *ProgramHandle {
+	sb.t.Helper()
+	h := sb.createObject(adt.ObjectTypeProgram, local, "adttest sandbox program")
+	h.writeSource(src)
+	return &ProgramHandle{h}
+}
+
+// CreateClass creates a global class named "ZMCP_<uid><NN><LOCAL>" with the
+// given main-include source and registers its cleanup.
+func (sb *Sandbox) CreateClass(local, src string) *ClassHandle {
+	sb.t.Helper()
+	h := sb.createObject(adt.ObjectTypeClass, local, "adttest sandbox class")
+	h.writeSource(src)
+	return &ClassHandle{h}
+}
+
+// CreateTable creates a DDIC table named "ZMCP_<uid><NN><LOCAL>" with the
+// given DDL source and registers its cleanup.
+func (sb *Sandbox) CreateTable(local, ddlSrc string) *TableHandle {
+	sb.t.Helper()
+	h := sb.createObject(adt.ObjectTypeTable, local, "adttest sandbox table")
+	h.writeSource(ddlSrc)
+	return &TableHandle{h}
+}
+
+// MustActivate activates h and fails the test if activation errors or
+// reports failure.
+func (sb *Sandbox) MustActivate(h interface {
+	Name() string
+	ObjectURL() string
+}) {
+	sb.t.Helper()
+	result, err := sb.client.Activate(context.Background(), h.ObjectURL(), h.Name())
+	if err != nil {
+		sb.t.Fatalf("adttest: activate %s: %v", h.Name(), err)
+	}
+	if !result.Success {
+		sb.t.Fatalf("adttest: activation of %s reported failure (%d messages)", h.Name(), len(result.Messages))
+	}
+}
+
+// Snapshot is a point-in-time capture of every sandbox object's source,
+// taken by Sandbox.Snapshot and rolled back by Restore.
+type Snapshot struct {
+	sb     *Sandbox
+	source map[string]string // objectURL -> source at snapshot time
+}
+
+// Snapshot reads and records the current source of every object the
+// sandbox has created so far, so a test can Restore() it after an
+// assertion fails mid-workflow instead of leaving the object half-edited
+// for the next step.
+func (sb *Sandbox) Snapshot() *Snapshot {
+	sb.t.Helper()
+	snap := &Snapshot{sb: sb, source: make(map[string]string, len(sb.objects))}
+	ctx := context.Background()
+	for _, h := range sb.objects {
+		src, err := sb.readSource(ctx, h)
+		if err != nil {
+			sb.t.Fatalf("adttest: snapshot %s: %v", h.name, err)
+		}
+		snap.source[h.objectURL] = src
+	}
+	return snap
+}
+
+// Restore rewrites and reactivates every object captured in the snapshot
+// back to its snapshotted source. Objects created after the snapshot are
+// left untouched.
+func (snap *Snapshot) Restore() {
+	t := snap.sb.t
+	t.Helper()
+	for _, h := range snap.sb.objects {
+		src, ok := snap.source[h.objectURL]
+		if !ok {
+			continue
+		}
+		h.writeSource(src)
+		result, err := snap.sb.client.Activate(context.Background(), h.objectURL, h.name)
+		if err != nil {
+			t.Errorf("adttest: reactivate %s after restore: %v", h.name, err)
+			continue
+		}
+		if !result.Success {
+			t.Errorf("adttest: reactivation of %s after restore reported failure (%d messages)", h.name, len(result.Messages))
+		}
+	}
+}
+
+func (sb *Sandbox) readSource(ctx context.Context, h *objectHandle) (string, error) {
+	switch h.objectType {
+	case adt.ObjectTypeProgram:
+		return sb.client.GetProgram(ctx, h.name)
+	case adt.ObjectTypeClass:
+		sources, err := sb.client.GetClass(ctx, h.name)
+		if err != nil {
+			return "", err
+		}
+		src, ok := sources["main"]
+		if !ok {
+			return "", fmt.Errorf("adttest: class %s has no main include source", h.name)
+		}
+		return src, nil
+	case adt.ObjectTypeTable:
+		return sb.client.GetTable(ctx, h.name)
+	default:
+		return "", fmt.Errorf("adttest: don't know how to read source for object type %v", h.objectType)
+	}
+}
+
+// --- Crash-safe global sweep ---
+//
+// t.Cleanup only runs if the test process reaches the end of the test (or
+// panics within it); it does not help after a hard crash, a killed CI job,
+// or a test that calls os.Exit. SweepLeftovers deletes any ZMCP_* objects
+// still present in a package, so a TestMain can clear out whatever a
+// previous, less fortunate run left behind before the current run starts.
+
+// SweepLeftovers deletes every object in packageName whose name starts
+// with "ZMCP_", using client. Call it once from a package's TestMain,
+// before m.Run(), so a previous run's crash doesn't leak objects forever:
+//
+//	func TestMain(m *testing.M) {
+//	    client := adt.NewClient(os.Getenv("SAP_URL"), os.Getenv("SAP_USER"), os.Getenv("SAP_PASSWORD"))
+//	    if err := adttest.SweepLeftovers(client, adttest.DefaultPackage); err != nil {
+//	        log.Printf("adttest: sweep failed: %v", err)
+//	    }
+//	    os.Exit(m.Run())
+//	}
+func SweepLeftovers(client *adt.Client, packageName string) error {
+	ctx := context.Background()
+	pkg, err := client.GetPackage(ctx, packageName)
+	if err != nil {
+		return fmt.Errorf("adttest: list package %s: %w", packageName, err)
+	}
+
+	var errs []error
+	for _, obj := range pkg.Objects {
+		if !strings.HasPrefix(obj.Name, "ZMCP_") {
+			continue
+		}
+		if err := sweepOne(ctx, client, obj.URI); err != nil {
+			errs = append(errs, fmt.Errorf("adttest: sweep %s: %w", obj.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sweepOne(ctx context.Context, client *adt.Client, objectURL string) error {
+	lock, err := client.LockObject(ctx, objectURL, "MODIFY")
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteObject(ctx, objectURL, lock.LockHandle, ""); err != nil {
+		_ = client.UnlockObject(ctx, objectURL, lock.LockHandle)
+		return err
+	}
+	return nil
+}
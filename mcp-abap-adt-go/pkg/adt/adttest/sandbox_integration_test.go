@@ -0,0 +1,155 @@
+//go:build integration
+
+package adttest
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-abap-adt-go/pkg/adt"
+)
+
+// These tests exercise Sandbox itself against a real (or recorded) ADT
+// backend, so the create/lock/update/activate/cleanup plumbing it
+// centralizes is proven end to end rather than only its name() string
+// formatting. They follow the same -record/fixture convention as
+// pkg/adt/integration_test.go: skipped unless SAP_URL, SAP_USER,
+// SAP_PASSWORD are set or a recorded fixture already exists under
+// testdata/replay/.
+//
+//	go test -tags=integration -record -run TestSandbox ./pkg/adt/adttest/
+
+var recordFlag = flag.Bool("record", false, "record HTTP fixtures for integration tests (requires live SAP credentials)")
+
+func replayFixturePath(t *testing.T) string {
+	return filepath.Join("testdata", "replay", t.Name()+".ndjson")
+}
+
+func getIntegrationClient(t *testing.T) *adt.Client {
+	t.Helper()
+	url := os.Getenv("SAP_URL")
+	user := os.Getenv("SAP_USER")
+	pass := os.Getenv("SAP_PASSWORD")
+	fixture := replayFixturePath(t)
+
+	if url == "" || user == "" || pass == "" {
+		if *recordFlag {
+			t.Fatal("-record requires SAP_URL, SAP_USER, SAP_PASSWORD")
+		}
+		if _, err := os.Stat(fixture); err != nil {
+			t.Skip("SAP_URL, SAP_USER, SAP_PASSWORD required for integration tests (no recorded fixture found)")
+		}
+		return adt.NewClient("https://replay.invalid", "replay", "replay",
+			adt.WithClient("001"), adt.WithLanguage("EN"), adt.WithHTTPReplayer(fixture), adt.WithMetricsRegistry(nil))
+	}
+
+	client := os.Getenv("SAP_CLIENT")
+	if client == "" {
+		client = "001"
+	}
+	lang := os.Getenv("SAP_LANGUAGE")
+	if lang == "" {
+		lang = "EN"
+	}
+
+	opts := []adt.Option{
+		adt.WithClient(client),
+		adt.WithLanguage(lang),
+		adt.WithTimeout(30 * time.Second),
+		adt.WithMetricsRegistry(nil),
+	}
+	if *recordFlag {
+		if err := os.MkdirAll(filepath.Dir(fixture), 0o755); err != nil {
+			t.Fatalf("create replay fixture dir: %v", err)
+		}
+		opts = append(opts, adt.WithHTTPRecorder(fixture))
+	}
+	return adt.NewClient(url, user, pass, opts...)
+}
+
+// TestSandboxCreateProgramActivateAndCleanup proves the core claim of this
+// package: CreateProgram takes care of create+lock+write+unlock, and the
+// t.Cleanup it registers deletes the program again without the test having
+// to write any of that plumbing itself.
+func TestSandboxCreateProgramActivateAndCleanup(t *testing.T) {
+	client := getIntegrationClient(t)
+	sb := NewSandbox(t, client)
+
+	prog := sb.CreateProgram("MAIN", `REPORT zmcp_sandbox_test.
+WRITE 'hello from adttest sandbox'.`)
+	sb.MustActivate(prog)
+
+	source, err := client.GetProgram(context.Background(), prog.Name())
+	if err != nil {
+		t.Fatalf("GetProgram(%s): %v", prog.Name(), err)
+	}
+	if !strings.Contains(source, "hello from adttest sandbox") {
+		t.Errorf("activated program source = %q, want it to contain the written text", source)
+	}
+}
+
+// TestSandboxSnapshotRestoreRollsBackSourceChange proves Snapshot/Restore
+// roll an object back to a known-good baseline after a mutation, as the
+// request asked for.
+func TestSandboxSnapshotRestoreRollsBackSourceChange(t *testing.T) {
+	client := getIntegrationClient(t)
+	sb := NewSandbox(t, client)
+
+	prog := sb.CreateProgram("MAIN", `REPORT zmcp_sandbox_snap.
+WRITE 'before'.`)
+	sb.MustActivate(prog)
+
+	snap := sb.Snapshot()
+
+	// Simulate a test step that mutates the object then fails an
+	// assertion partway through the workflow, without reactivating.
+	prog.writeSource(`REPORT zmcp_sandbox_snap.
+WRITE 'after'.`)
+
+	snap.Restore()
+
+	source, err := client.GetProgram(context.Background(), prog.Name())
+	if err != nil {
+		t.Fatalf("GetProgram(%s): %v", prog.Name(), err)
+	}
+	if !strings.Contains(source, "before") {
+		t.Errorf("source after Restore = %q, want the snapshotted \"before\" content", source)
+	}
+}
+
+// TestSweepLeftoversRemovesUnreleasedObject proves the crash-recovery path:
+// an object created outside of any Sandbox's t.Cleanup (simulating what a
+// killed test run leaves behind) is still removed by SweepLeftovers.
+func TestSweepLeftoversRemovesUnreleasedObject(t *testing.T) {
+	client := getIntegrationClient(t)
+	ctx := context.Background()
+
+	name := "ZMCP_" + shortUID() + "ORPHAN"
+	if err := client.CreateObject(ctx, adt.CreateObjectOptions{
+		ObjectType:  adt.ObjectTypeProgram,
+		Name:        name,
+		Description: "adttest sweep test orphan",
+		PackageName: DefaultPackage,
+	}); err != nil {
+		t.Fatalf("create orphan program: %v", err)
+	}
+
+	if err := SweepLeftovers(client, DefaultPackage); err != nil {
+		t.Fatalf("SweepLeftovers: %v", err)
+	}
+
+	pkg, err := client.GetPackage(ctx, DefaultPackage)
+	if err != nil {
+		t.Fatalf("GetPackage(%s): %v", DefaultPackage, err)
+	}
+	for _, obj := range pkg.Objects {
+		if obj.Name == name {
+			t.Errorf("SweepLeftovers left %s behind in %s", name, DefaultPackage)
+		}
+	}
+}
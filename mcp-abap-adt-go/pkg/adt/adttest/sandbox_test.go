@@ -0,0 +1,76 @@
+package adttest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSandboxNameIsUniqueAndPrefixed(t *testing.T) {
+	sb := NewSandbox(t, nil)
+
+	first := sb.name("MAIN")
+	second := sb.name("MAIN")
+
+	if first == second {
+		t.Errorf("sb.name(\"MAIN\") returned the same name twice: %q", first)
+	}
+	if !strings.HasPrefix(first, "ZMCP_") {
+		t.Errorf("sb.name(\"MAIN\") = %q, want ZMCP_ prefix", first)
+	}
+	if !strings.HasSuffix(first, "MAIN") {
+		t.Errorf("sb.name(\"MAIN\") = %q, want MAIN suffix", first)
+	}
+}
+
+func TestSandboxNameTruncatesTo30Chars(t *testing.T) {
+	sb := NewSandbox(t, nil)
+
+	name := sb.name(strings.Repeat("X", 40))
+
+	if len(name) > 30 {
+		t.Errorf("len(name) = %d, want <= 30", len(name))
+	}
+}
+
+func TestSandboxNameUppercasesLocal(t *testing.T) {
+	sb := NewSandbox(t, nil)
+
+	name := sb.name("lower")
+
+	if !strings.HasSuffix(name, "LOWER") {
+		t.Errorf("sb.name(\"lower\") = %q, want uppercased LOWER suffix", name)
+	}
+}
+
+// httpStatusError is a minimal statusCoder, standing in for whatever error
+// type adt.Client's methods actually return for a failed HTTP call.
+type httpStatusError struct {
+	status int
+}
+
+func (e httpStatusError) Error() string  { return fmt.Sprintf("http status %d", e.status) }
+func (e httpStatusError) StatusCode() int { return e.status }
+
+func TestIsNotFoundErr(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"404", httpStatusError{status: http.StatusNotFound}, true},
+		{"wrapped 404", fmt.Errorf("lock: %w", httpStatusError{status: http.StatusNotFound}), true},
+		{"403", httpStatusError{status: http.StatusForbidden}, false},
+		{"plain error with no status", errors.New("connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotFoundErr(tt.err); got != tt.want {
+				t.Errorf("isNotFoundErr(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
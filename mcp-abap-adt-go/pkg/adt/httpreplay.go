@@ -0,0 +1,372 @@
+package adt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// replayEntry is one recorded request/response pair. A fixture file is a
+// sequence of these, one per line, encoded as newline-delimited JSON so
+// fixtures diff cleanly and can be appended to while recording.
+type replayEntry struct {
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Query       string            `json:"query"`
+	BodyHash    string            `json:"body_hash"`
+	ReqHeaders  map[string]string `json:"req_headers,omitempty"`
+	ReqBody     string            `json:"req_body,omitempty"`
+	StatusCode  int               `json:"status_code"`
+	RespHeaders map[string]string `json:"resp_headers,omitempty"`
+	RespBody    string            `json:"resp_body"`
+}
+
+// Headers whose values are credentials. scrubHeaders always records them,
+// but with these fixed placeholder values in place of the real ones.
+var replaySecretHeaders = map[string]string{
+	"authorization": "REDACTED-AUTHORIZATION",
+	"cookie":        "REDACTED-COOKIE",
+	"set-cookie":    "REDACTED-COOKIE",
+}
+
+// replayCSRFPlaceholder stands in for the real x-csrf-token value so that a
+// token minted on the day of recording still matches a replay run on any
+// later day. The literal "fetch" is left untouched: it is the well-known
+// trigger value ADT servers look for on the initial token request, not a
+// secret.
+const replayCSRFPlaceholder = "REPLAY-CSRF-TOKEN"
+
+// replayLockHandlePlaceholder stands in for SAP lock handles, which are
+// opaque, freshly generated per LockObject call and therefore never equal
+// between a recording session and a replay session.
+const replayLockHandlePlaceholder = "REPLAY-LOCK-HANDLE"
+
+var multipartBoundaryRe = regexp.MustCompile(`boundary=("?)([^"; ]+)("?)`)
+
+// replayBoundaryPlaceholder replaces the random MIME boundary multipart
+// class-source responses are served with, so the same response body hashes
+// identically across re-recordings.
+const replayBoundaryPlaceholder = "REPLAYBOUNDARY"
+
+// canonicalizeContentType rewrites a multipart Content-Type header's random
+// boundary parameter to replayBoundaryPlaceholder. Without this, the
+// boundary recorded in the header would stay random while canonicalizeBody
+// rewrites the same boundary's delimiters in the body, so a real multipart
+// parser given the recorded header and replayed body would look for a
+// boundary the body no longer contains.
+func canonicalizeContentType(contentType string) string {
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return contentType
+	}
+	return multipartBoundaryRe.ReplaceAllString(contentType, "boundary=${1}"+replayBoundaryPlaceholder+"${3}")
+}
+
+// scrubHeaders copies h into a plain map suitable for a fixture file,
+// dropping or replacing anything that is a credential rather than a
+// matching key.
+func scrubHeaders(h http.Header, preserveKeys map[string]bool) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if len(v) == 0 {
+			continue
+		}
+		lk := strings.ToLower(k)
+		if placeholder, ok := replaySecretHeaders[lk]; ok {
+			out[lk] = placeholder
+			continue
+		}
+		if !preserveKeys[lk] {
+			continue
+		}
+		value := v[0]
+		if lk == "x-csrf-token" && !strings.EqualFold(value, "fetch") {
+			value = replayCSRFPlaceholder
+		}
+		if lk == "content-type" {
+			value = canonicalizeContentType(value)
+		}
+		out[lk] = value
+	}
+	return out
+}
+
+// replayMatchHeaders is the set of non-credential headers significant
+// enough to record in a fixture. Everything else (User-Agent,
+// Accept-Encoding, ...) is noise and is not recorded. Replayer does not
+// compare headers when matching a request.
+var replayMatchHeaders = map[string]bool{
+	"x-csrf-token": true,
+	"sap-client":   true,
+	"content-type": true,
+}
+
+// canonicalizeQuery rewrites query parameters that hold freshly generated,
+// non-reproducible values (lock handles) to a stable placeholder, so the
+// same logical request matches across recordings.
+func canonicalizeQuery(q url.Values) string {
+	out := url.Values{}
+	for k, v := range q {
+		lk := strings.ToLower(k)
+		if lk == "lockhandle" {
+			out[k] = []string{replayLockHandlePlaceholder}
+			continue
+		}
+		out[k] = v
+	}
+	return out.Encode()
+}
+
+// canonicalizeBody rewrites the non-reproducible multipart boundary of a
+// request/response body. Lock handles embedded in a body (such as a
+// LockObject response) are kept as recorded; only the lockHandle query
+// parameter is canonicalized, by canonicalizeQuery.
+func canonicalizeBody(body []byte, contentType string) []byte {
+	if mt, params, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mt, "multipart/") {
+		if boundary := params["boundary"]; boundary != "" {
+			body = bytes.ReplaceAll(body, []byte(boundary), []byte(replayBoundaryPlaceholder))
+		}
+	}
+	return body
+}
+
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func canonicalContentType(h http.Header) string {
+	return h.Get("Content-Type")
+}
+
+// drainBody reads req/resp body to completion and replaces it with a fresh
+// reader so the real RoundTripper downstream can still consume it.
+func drainBody(body io.ReadCloser) (io.ReadCloser, []byte, error) {
+	if body == nil {
+		return http.NoBody, nil, nil
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), data, nil
+}
+
+// Recorder is an http.RoundTripper that passes every request through to a
+// real transport and appends the request/response pair to a fixture file,
+// scrubbing credentials and canonicalizing non-reproducible values as it
+// goes. Use it via WithHTTPRecorder.
+type Recorder struct {
+	next http.RoundTripper
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewRecorder creates a Recorder that writes to the fixture file at path,
+// truncating any existing content, wrapping next (http.DefaultTransport if nil) to perform the real calls.
+func NewRecorder(path string, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("adt: open replay fixture %q: %w", path, err)
+	}
+	return &Recorder{next: next, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Close flushes and closes the underlying fixture file.
+func (r *Recorder) Close() error {
+	return r.f.Close()
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBodyBytes []byte
+	if req.Body != nil {
+		body, data, err := drainBody(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("adt: recorder read request body: %w", err)
+		}
+		req.Body = body
+		reqBodyBytes = data
+	}
+	canonicalReqBody := canonicalizeBody(reqBodyBytes, canonicalContentType(req.Header))
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	respBody, respBytes, derr := drainBody(resp.Body)
+	if derr != nil {
+		return resp, fmt.Errorf("adt: recorder read response body: %w", derr)
+	}
+	resp.Body = respBody
+	canonicalRespBody := canonicalizeBody(respBytes, canonicalContentType(resp.Header))
+
+	entry := replayEntry{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Query:       canonicalizeQuery(req.URL.Query()),
+		BodyHash:    hashBody(canonicalReqBody),
+		ReqHeaders:  scrubHeaders(req.Header, replayMatchHeaders),
+		ReqBody:     string(canonicalReqBody),
+		StatusCode:  resp.StatusCode,
+		RespHeaders: scrubHeaders(resp.Header, replayMatchHeaders),
+		RespBody:    string(canonicalRespBody),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if encErr := r.enc.Encode(entry); encErr != nil {
+		return resp, fmt.Errorf("adt: write replay fixture entry: %w", encErr)
+	}
+	return resp, nil
+}
+
+// Replayer is an http.RoundTripper that serves recorded responses from a
+// fixture file instead of making real HTTP calls. Use it via
+// WithHTTPReplayer.
+type Replayer struct {
+	mu      sync.Mutex
+	entries []replayEntry
+	used    []bool
+}
+
+// NewReplayer loads the fixture file at path for replay.
+func NewReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("adt: open replay fixture %q: %w", path, err)
+	}
+	defer f.Close()
+
+	var entries []replayEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e replayEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("adt: parse replay fixture %q: %w", path, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("adt: read replay fixture %q: %w", path, err)
+	}
+	return &Replayer{entries: entries, used: make([]bool, len(entries))}, nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBodyBytes []byte
+	if req.Body != nil {
+		body, data, err := drainBody(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("adt: replayer read request body: %w", err)
+		}
+		req.Body = body
+		reqBodyBytes = data
+	}
+	canonicalReqBody := canonicalizeBody(reqBodyBytes, canonicalContentType(req.Header))
+
+	wantQuery := canonicalizeQuery(req.URL.Query())
+	wantHash := hashBody(canonicalReqBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, e := range r.entries {
+		if r.used[i] {
+			continue
+		}
+		if e.Method == req.Method && e.Path == req.URL.Path && e.Query == wantQuery && e.BodyHash == wantHash {
+			r.used[i] = true
+			return r.buildResponse(e, req), nil
+		}
+	}
+
+	return nil, fmt.Errorf("adt: no recorded response matches %s %s?%s (body sha256 %s); closest candidates:\n%s",
+		req.Method, req.URL.Path, wantQuery, wantHash, r.diffCandidates(req.Method, req.URL.Path))
+}
+
+func (r *Replayer) buildResponse(e replayEntry, req *http.Request) *http.Response {
+	header := make(http.Header, len(e.RespHeaders))
+	for k, v := range e.RespHeaders {
+		header.Set(k, v)
+	}
+	return &http.Response{
+		StatusCode: e.StatusCode,
+		Status:     fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(e.RespBody)),
+		Request:    req,
+	}
+}
+
+// diffCandidates returns a short, human-readable summary of the unused
+// fixture entries for the same method and path, to help a developer see
+// why a replay match failed (e.g. a stale lock handle or query param).
+func (r *Replayer) diffCandidates(method, path string) string {
+	var candidates []string
+	for i, e := range r.entries {
+		if r.used[i] || e.Method != method || e.Path != path {
+			continue
+		}
+		candidates = append(candidates, fmt.Sprintf("  query=%q body_hash=%s", e.Query, e.BodyHash))
+	}
+	if len(candidates) == 0 {
+		return "  (none recorded for this method and path)"
+	}
+	sort.Strings(candidates)
+	return strings.Join(candidates, "\n")
+}
+
+// WithHTTPRecorder wraps the client's transport so every request and
+// response is appended to the newline-delimited JSON fixture file at path,
+// with credentials scrubbed and non-reproducible values (CSRF tokens, lock
+// handles, multipart boundaries) canonicalized. Pair with
+// WithHTTPReplayer to run the same integration tests hermetically.
+func WithHTTPRecorder(path string) Option {
+	return func(c *Client) {
+		rec, err := NewRecorder(path, c.httpClient.Transport)
+		if err != nil {
+			panic(err)
+		}
+		c.httpClient.Transport = rec
+	}
+}
+
+// WithHTTPReplayer replaces the client's transport with one that serves
+// recorded responses from the fixture file at path instead of making real
+// HTTP calls. A request that does not match any unused recorded entry
+// fails with a diff against the closest candidates.
+func WithHTTPReplayer(path string) Option {
+	return func(c *Client) {
+		replayer, err := NewReplayer(path)
+		if err != nil {
+			panic(err)
+		}
+		c.httpClient.Transport = replayer
+	}
+}
@@ -0,0 +1,219 @@
+package adt
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScrubHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Basic dXNlcjpwYXNz")
+	h.Set("Cookie", "sap-session=abc123")
+	h.Set("X-Csrf-Token", "real-token-value")
+	h.Set("Sap-Client", "001")
+	h.Set("User-Agent", "mcp-abap-adt-go")
+
+	got := scrubHeaders(h, replayMatchHeaders)
+
+	if got["authorization"] != "REDACTED-AUTHORIZATION" {
+		t.Errorf("Authorization not scrubbed: %q", got["authorization"])
+	}
+	if got["cookie"] != "REDACTED-COOKIE" {
+		t.Errorf("Cookie not scrubbed: %q", got["cookie"])
+	}
+	if got["x-csrf-token"] != replayCSRFPlaceholder {
+		t.Errorf("x-csrf-token not canonicalized: %q", got["x-csrf-token"])
+	}
+	if got["sap-client"] != "001" {
+		t.Errorf("sap-client should be preserved, got %q", got["sap-client"])
+	}
+	if _, ok := got["user-agent"]; ok {
+		t.Error("User-Agent should not be recorded")
+	}
+}
+
+func TestScrubHeadersPreservesCSRFFetch(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Csrf-Token", "fetch")
+
+	got := scrubHeaders(h, replayMatchHeaders)
+
+	if got["x-csrf-token"] != "fetch" {
+		t.Errorf("fetch trigger value should be left untouched, got %q", got["x-csrf-token"])
+	}
+}
+
+func TestCanonicalizeQueryStabilizesLockHandle(t *testing.T) {
+	q1 := url.Values{"_action": {"UNLOCK"}, "lockHandle": {"abc111"}}
+	q2 := url.Values{"_action": {"UNLOCK"}, "lockHandle": {"zzz999"}}
+
+	if canonicalizeQuery(q1) != canonicalizeQuery(q2) {
+		t.Errorf("different lock handles should canonicalize to the same query, got %q vs %q",
+			canonicalizeQuery(q1), canonicalizeQuery(q2))
+	}
+}
+
+func TestCanonicalizeBodyRewritesMultipartBoundary(t *testing.T) {
+	body := []byte("--myboundary123\r\nContent-Type: text/plain\r\n\r\nhello\r\n--myboundary123--")
+	out := canonicalizeBody(body, `multipart/mixed; boundary=myboundary123`)
+
+	if string(out) == string(body) {
+		t.Fatal("expected boundary to be rewritten")
+	}
+	if hashBody(out) != hashBody(canonicalizeBody([]byte("--otherboundary\r\nContent-Type: text/plain\r\n\r\nhello\r\n--otherboundary--"), "multipart/mixed; boundary=otherboundary")) {
+		t.Error("two bodies differing only by boundary should hash identically once canonicalized")
+	}
+}
+
+func TestCanonicalizeContentTypeRewritesBoundary(t *testing.T) {
+	got := canonicalizeContentType(`multipart/mixed; boundary=myboundary123`)
+
+	if got != `multipart/mixed; boundary=`+replayBoundaryPlaceholder {
+		t.Errorf("canonicalizeContentType() = %q, want boundary rewritten to %q", got, replayBoundaryPlaceholder)
+	}
+	if canonicalizeContentType("application/json") != "application/json" {
+		t.Error("canonicalizeContentType() should leave non-multipart content types untouched")
+	}
+}
+
+// fixedTransport always returns resp, ignoring the request.
+type fixedTransport struct{ resp *http.Response }
+
+func (f fixedTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return f.resp, nil
+}
+
+// multipartBody builds a two-part multipart/mixed body and returns it along
+// with the Content-Type header value carrying its boundary.
+func multipartBody(t *testing.T) ([]byte, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreatePart(map[string][]string{"Content-Type": {"text/plain"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write([]byte("REPORT zmcp_main.")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), "multipart/mixed; boundary=" + w.Boundary()
+}
+
+// TestRecordReplayRoundTripPreservesMultipartParseability records a real
+// multipart/mixed response (the shape GetClass receives for class sources)
+// to a fixture file and replays it, verifying that a real mime/multipart
+// reader can still parse the replayed response: the boundary rewritten in
+// the body by canonicalizeBody must match the boundary served in the
+// replayed Content-Type header.
+func TestRecordReplayRoundTripPreservesMultipartParseability(t *testing.T) {
+	body, contentType := multipartBody(t)
+
+	recorded := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+
+	path := filepath.Join(t.TempDir(), "fixture.jsonl")
+	rec, err := NewRecorder(path, fixedTransport{resp: recorded})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/sap/bc/adt/oo/classes/zmcp_main/source/main", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.RoundTrip(req); err != nil {
+		t.Fatalf("record RoundTrip failed: %v", err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := replayer.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("replay RoundTrip failed: %v", err)
+	}
+
+	mt, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mt, "multipart/") {
+		t.Fatalf("replayed Content-Type = %q, want a parseable multipart media type: %v", resp.Header.Get("Content-Type"), err)
+	}
+
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	p, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("replayed body did not parse against replayed Content-Type boundary %q: %v", params["boundary"], err)
+	}
+	got, err := io.ReadAll(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "REPORT zmcp_main." {
+		t.Errorf("replayed part body = %q, want %q", got, "REPORT zmcp_main.")
+	}
+}
+
+func TestReplayerMatchesRecordedRequest(t *testing.T) {
+	r := &Replayer{
+		entries: []replayEntry{
+			{
+				Method:     http.MethodGet,
+				Path:       "/sap/bc/adt/programs/programs/SAPMSSY0",
+				Query:      "",
+				BodyHash:   hashBody(nil),
+				StatusCode: http.StatusOK,
+				RespBody:   "REPORT sapmssy0.",
+			},
+		},
+		used: make([]bool, 1),
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/sap/bc/adt/programs/programs/SAPMSSY0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := r.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want 200", resp.StatusCode)
+	}
+	if !r.used[0] {
+		t.Error("matched entry should be marked used")
+	}
+
+	if _, err := r.RoundTrip(req); err == nil {
+		t.Error("replaying the same request twice should fail: entry already consumed")
+	}
+}
+
+func TestReplayerUnmatchedRequestFails(t *testing.T) {
+	r := &Replayer{entries: nil, used: nil}
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/sap/bc/adt/programs/programs/UNKNOWN", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.RoundTrip(req); err == nil {
+		t.Error("expected an error for a request with no recorded match")
+	}
+}
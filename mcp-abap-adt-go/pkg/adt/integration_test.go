@@ -4,23 +4,64 @@ package adt
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // Integration tests require SAP_URL, SAP_USER, SAP_PASSWORD environment variables.
 // Run with: go test -tags=integration -v ./pkg/adt/
+//
+// Without live credentials, a test still runs if a recorded fixture exists
+// under testdata/replay/<TestName>.ndjson, serving responses from that
+// fixture instead of a real SAP backend. With live credentials, pass
+// -record to (re-)record the fixture for the current test as it runs:
+//
+//	go test -tags=integration -record -run TestIntegration_SearchObject ./pkg/adt/
+
+var recordFlag = flag.Bool("record", false, "record HTTP fixtures for integration tests (requires live SAP credentials)")
+
+func replayFixturePath(t *testing.T) string {
+	return filepath.Join("testdata", "replay", t.Name()+".ndjson")
+}
 
 func getIntegrationClient(t *testing.T) *Client {
 	url := os.Getenv("SAP_URL")
 	user := os.Getenv("SAP_USER")
 	pass := os.Getenv("SAP_PASSWORD")
+	fixture := replayFixturePath(t)
 
 	if url == "" || user == "" || pass == "" {
-		t.Skip("SAP_URL, SAP_USER, SAP_PASSWORD required for integration tests")
+		if *recordFlag {
+			t.Fatal("-record requires SAP_URL, SAP_USER, SAP_PASSWORD")
+		}
+		if _, err := os.Stat(fixture); err != nil {
+			t.Skip("SAP_URL, SAP_USER, SAP_PASSWORD required for integration tests (no recorded fixture found)")
+		}
+		client := NewClient("https://replay.invalid", "replay", "replay",
+			WithClient("001"), WithLanguage("EN"), WithHTTPReplayer(fixture), WithMetricsRegistry(nil))
+		t.Cleanup(func() {
+			transport := client.httpClient.Transport
+			if mrt, ok := transport.(*metricsRoundTripper); ok {
+				transport = mrt.next
+			}
+			if replayer, ok := transport.(*Replayer); ok {
+				replayer.mu.Lock()
+				defer replayer.mu.Unlock()
+				for i, used := range replayer.used {
+					if !used {
+						t.Errorf("fixture entry %d (%s %s) was never replayed", i, replayer.entries[i].Method, replayer.entries[i].Path)
+					}
+				}
+			}
+		})
+		return client
 	}
 
 	client := os.Getenv("SAP_CLIENT")
@@ -36,13 +77,31 @@ func getIntegrationClient(t *testing.T) *Client {
 		WithClient(client),
 		WithLanguage(lang),
 		WithTimeout(30 * time.Second),
+		WithMetricsRegistry(nil),
 	}
 
 	if os.Getenv("SAP_INSECURE") == "true" {
 		opts = append(opts, WithInsecureSkipVerify())
 	}
 
-	return NewClient(url, user, pass, opts...)
+	if *recordFlag {
+		if err := os.MkdirAll(filepath.Dir(fixture), 0o755); err != nil {
+			t.Fatalf("create replay fixture dir: %v", err)
+		}
+		opts = append(opts, WithHTTPRecorder(fixture))
+	}
+
+	c := NewClient(url, user, pass, opts...)
+	if *recordFlag {
+		t.Cleanup(func() {
+			if recorder, ok := c.httpClient.Transport.(*Recorder); ok {
+				if err := recorder.Close(); err != nil {
+					t.Errorf("close replay fixture: %v", err)
+				}
+			}
+		})
+	}
+	return c
 }
 
 func TestIntegration_SearchObject(t *testing.T) {
@@ -282,6 +341,9 @@ func TestIntegration_CRUD_FullWorkflow(t *testing.T) {
 	client := getIntegrationClient(t)
 	ctx := context.Background()
 
+	lockBefore := testutil.ToFloat64(client.metrics.LockAcquireTotal.WithLabelValues("success"))
+	requestsBefore := requestsTotalSum(t, client.metrics)
+
 	// Use a unique test program name with timestamp to avoid conflicts
 	timestamp := time.Now().Unix() % 100000 // Last 5 digits
 	programName := fmt.Sprintf("ZMCP_%05d", timestamp)
@@ -379,6 +441,39 @@ WRITE 'Hello from MCP!'.`
 	}
 
 	t.Log("CRUD workflow completed successfully!")
+
+	// Step 7: Verify the instrumentation in pkg/adt/metrics.go observed
+	// this workflow: at least the step 2 LockObject call (the cleanup lock
+	// only runs later, in the deferred cleanup) and a handful of other
+	// requests should have been counted.
+	lockAfter := testutil.ToFloat64(client.metrics.LockAcquireTotal.WithLabelValues("success"))
+	if lockAfter < lockBefore+1 {
+		t.Errorf("adt_lock_acquire_total{result=\"success\"} = %v, want at least %v", lockAfter, lockBefore+1)
+	}
+	requestsAfter := requestsTotalSum(t, client.metrics)
+	if requestsAfter <= requestsBefore {
+		t.Errorf("adt_http_requests_total did not grow during the CRUD workflow: before=%v after=%v", requestsBefore, requestsAfter)
+	}
+}
+
+// requestsTotalSum returns adt_http_requests_total summed over all of its
+// label series, i.e. the number of requests m has counted.
+func requestsTotalSum(t *testing.T, m *Metrics) float64 {
+	t.Helper()
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	var sum float64
+	for _, mf := range families {
+		if mf.GetName() != "adt_http_requests_total" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			sum += metric.GetCounter().GetValue()
+		}
+	}
+	return sum
 }
 
 // TestIntegration_LockUnlock tests just the lock/unlock cycle
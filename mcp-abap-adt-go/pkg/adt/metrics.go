@@ -0,0 +1,215 @@
+package adt
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// requestDurationBuckets covers the range ADT calls typically fall into:
+// from a fast metadata lookup (tens of milliseconds) to a slow RunQuery or
+// RunUnitTests call against a loaded system (tens of seconds).
+var requestDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30}
+
+// Metrics holds the Prometheus collectors for a Client's HTTP traffic.
+// Construct one with NewMetrics, or use DefaultMetrics for the package-wide
+// default registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	RequestsTotal       *prometheus.CounterVec
+	RequestDuration     *prometheus.HistogramVec
+	CSRFTokenFetchTotal prometheus.Counter
+	LockAcquireTotal    *prometheus.CounterVec
+}
+
+// NewMetrics creates and registers the ADT client collectors on reg.
+func NewMetrics(reg *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		registry: reg,
+		RequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "adt_http_requests_total",
+			Help: "Total number of ADT HTTP requests, by logical operation, method and status.",
+		}, []string{"operation", "method", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "adt_http_request_duration_seconds",
+			Help:    "Latency of ADT HTTP requests, by logical operation.",
+			Buckets: requestDurationBuckets,
+		}, []string{"operation"}),
+		CSRFTokenFetchTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "adt_csrf_token_fetch_total",
+			Help: "Total number of x-csrf-token fetch requests issued.",
+		}),
+		LockAcquireTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "adt_lock_acquire_total",
+			Help: "Total number of LockObject attempts, by result (success or failure).",
+		}, []string{"result"}),
+	}
+	reg.MustRegister(m.RequestsTotal, m.RequestDuration, m.CSRFTokenFetchTotal, m.LockAcquireTotal)
+	return m
+}
+
+// Handler returns an http.Handler serving these metrics in the Prometheus
+// exposition format, suitable for mounting at /metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+var (
+	defaultMetrics     *Metrics
+	defaultMetricsOnce sync.Once
+)
+
+// DefaultMetrics returns the package-wide default Metrics, registered on a
+// dedicated registry the first time it is called.
+func DefaultMetrics() *Metrics {
+	defaultMetricsOnce.Do(func() {
+		defaultMetrics = NewMetrics(prometheus.NewRegistry())
+	})
+	return defaultMetrics
+}
+
+// MetricsHandler returns an http.Handler for DefaultMetrics, suitable for
+// mounting at /metrics in a standalone or MCP server process.
+func MetricsHandler() http.Handler {
+	return DefaultMetrics().Handler()
+}
+
+// WithMetricsRegistry instruments the client's HTTP traffic with the ADT
+// collectors, registering them on reg. Pass nil to use DefaultMetrics
+// instead of a caller-owned registry.
+func WithMetricsRegistry(reg *prometheus.Registry) Option {
+	return func(c *Client) {
+		if reg == nil {
+			c.metrics = DefaultMetrics()
+		} else {
+			c.metrics = NewMetrics(reg)
+		}
+		c.httpClient.Transport = &metricsRoundTripper{
+			next:    c.httpClient.Transport,
+			metrics: c.metrics,
+		}
+	}
+}
+
+// metricsRoundTripper observes every request's operation, method, status
+// and latency. The operation is classified from the request's URL and
+// query rather than the raw path, which is high-cardinality (object names
+// are embedded in ADT URLs) and would make the requests_total series
+// unbounded.
+type metricsRoundTripper struct {
+	next    http.RoundTripper
+	metrics *Metrics
+}
+
+func (rt *metricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation := classifyOperation(req)
+	start := time.Now()
+
+	next := rt.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	duration := time.Since(start).Seconds()
+
+	rt.metrics.RequestDuration.WithLabelValues(operation).Observe(duration)
+
+	status := "error"
+	if resp != nil {
+		status = statusLabel(resp.StatusCode)
+	}
+	rt.metrics.RequestsTotal.WithLabelValues(operation, req.Method, status).Inc()
+
+	if operation == "CSRFTokenFetch" {
+		rt.metrics.CSRFTokenFetchTotal.Inc()
+	}
+	if operation == "LockObject" {
+		result := "success"
+		if err != nil || resp == nil || resp.StatusCode >= 400 {
+			result = "failure"
+		}
+		rt.metrics.LockAcquireTotal.WithLabelValues(result).Inc()
+	}
+
+	return resp, err
+}
+
+// statusLabel renders an HTTP status code as its label string without
+// pulling in strconv for a value that is always in [100, 599].
+func statusLabel(statusCode int) string {
+	digits := [3]byte{}
+	n := statusCode
+	for i := 2; i >= 0; i-- {
+		digits[i] = byte('0' + n%10)
+		n /= 10
+	}
+	return string(digits[:])
+}
+
+// classifyOperation maps a request's URL path, query and method to the
+// logical ADT operation name used for metric labels. Lock/unlock, source
+// update and activation share the same base paths as the object's own
+// read/create/delete endpoints, so the `_action` query parameter and
+// path suffix are checked before falling back to path classification.
+func classifyOperation(req *http.Request) string {
+	path := req.URL.Path
+	query := req.URL.Query()
+
+	switch query.Get("_action") {
+	case "LOCK", "MODIFY":
+		return "LockObject"
+	case "UNLOCK":
+		return "UnlockObject"
+	}
+
+	switch {
+	case strings.HasSuffix(path, "/activate"):
+		return "Activate"
+	case strings.Contains(path, "/source/main") && req.Method == http.MethodPut:
+		return "UpdateSource"
+	case strings.Contains(path, "/sap/bc/adt/discovery"):
+		if req.Header.Get("x-csrf-token") == "fetch" {
+			return "CSRFTokenFetch"
+		}
+		return "Discovery"
+	case strings.Contains(path, "/sap/bc/adt/repository/informationsystem/search"):
+		return "SearchObject"
+	case strings.Contains(path, "/sap/bc/adt/programs/programs"):
+		return classifyBySourceOrCRUD(req, "Program")
+	case strings.Contains(path, "/sap/bc/adt/oo/classes"):
+		return classifyBySourceOrCRUD(req, "Class")
+	case strings.Contains(path, "/sap/bc/adt/datapreview/freestyle"):
+		return "RunQuery"
+	case strings.Contains(path, "/sap/bc/adt/datapreview"):
+		return "GetTableContents"
+	case strings.Contains(path, "/sap/bc/adt/ddic/tables"):
+		return "GetTable"
+	case strings.Contains(path, "/sap/bc/adt/packages"):
+		return "GetPackage"
+	case strings.Contains(path, "/sap/bc/adt/checkruns"):
+		return "SyntaxCheck"
+	case strings.Contains(path, "/sap/bc/adt/abapunit"):
+		return "RunUnitTests"
+	default:
+		return "Unknown"
+	}
+}
+
+// classifyBySourceOrCRUD disambiguates the handful of operations that
+// share a base path (programs/classes): plain GET reads the object,
+// POST/DELETE with no other markers create or delete it.
+func classifyBySourceOrCRUD(req *http.Request, kind string) string {
+	switch req.Method {
+	case http.MethodPost:
+		return "CreateObject"
+	case http.MethodDelete:
+		return "DeleteObject"
+	default:
+		return "Get" + kind
+	}
+}
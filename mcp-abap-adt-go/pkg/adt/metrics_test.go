@@ -0,0 +1,61 @@
+package adt
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestClassifyOperation(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		header http.Header
+		want   string
+	}{
+		{"csrf fetch", http.MethodHead, "https://x/sap/bc/adt/discovery", http.Header{"X-Csrf-Token": {"fetch"}}, "CSRFTokenFetch"},
+		{"discovery without fetch", http.MethodHead, "https://x/sap/bc/adt/discovery", nil, "Discovery"},
+		{"search", http.MethodGet, "https://x/sap/bc/adt/repository/informationsystem/search?query=CL_*", nil, "SearchObject"},
+		{"get program", http.MethodGet, "https://x/sap/bc/adt/programs/programs/ZFOO", nil, "GetProgram"},
+		{"create program", http.MethodPost, "https://x/sap/bc/adt/programs/programs", nil, "CreateObject"},
+		{"delete program", http.MethodDelete, "https://x/sap/bc/adt/programs/programs/ZFOO", nil, "DeleteObject"},
+		{"get class", http.MethodGet, "https://x/sap/bc/adt/oo/classes/ZCL_FOO", nil, "GetClass"},
+		{"lock", http.MethodPost, "https://x/sap/bc/adt/programs/programs/ZFOO?_action=LOCK&accessMode=MODIFY", nil, "LockObject"},
+		{"unlock", http.MethodPost, "https://x/sap/bc/adt/programs/programs/ZFOO?_action=UNLOCK&lockHandle=abc", nil, "UnlockObject"},
+		{"update source", http.MethodPut, "https://x/sap/bc/adt/programs/programs/ZFOO/source/main", nil, "UpdateSource"},
+		{"activate", http.MethodPost, "https://x/sap/bc/adt/activation/activate", nil, "Activate"},
+		{"table contents", http.MethodGet, "https://x/sap/bc/adt/datapreview/ddic/T000?rowNumber=5", nil, "GetTableContents"},
+		{"run query", http.MethodPost, "https://x/sap/bc/adt/datapreview/freestyle", nil, "RunQuery"},
+		{"get table", http.MethodGet, "https://x/sap/bc/adt/ddic/tables/T000/source/main", nil, "GetTable"},
+		{"get package", http.MethodGet, "https://x/sap/bc/adt/packages/BASIS", nil, "GetPackage"},
+		{"syntax check", http.MethodPost, "https://x/sap/bc/adt/checkruns", nil, "SyntaxCheck"},
+		{"run unit tests", http.MethodPost, "https://x/sap/bc/adt/abapunit/testruns", nil, "RunUnitTests"},
+		{"unknown", http.MethodGet, "https://x/sap/bc/adt/something/else", nil, "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := &http.Request{Method: tt.method, URL: u, Header: tt.header}
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			if got := classifyOperation(req); got != tt.want {
+				t.Errorf("classifyOperation(%s %s) = %q, want %q", tt.method, tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusLabel(t *testing.T) {
+	if got := statusLabel(200); got != "200" {
+		t.Errorf("statusLabel(200) = %q, want \"200\"", got)
+	}
+	if got := statusLabel(503); got != "503" {
+		t.Errorf("statusLabel(503) = %q, want \"503\"", got)
+	}
+}
@@ -0,0 +1,257 @@
+package adt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RetryDecision is the outcome of classifying a failed (or successful)
+// attempt: whether the retry layer should give up, retry as-is, or
+// re-authenticate (and, for lock-bound writes, re-acquire any lock) before
+// retrying.
+type RetryDecision int
+
+const (
+	// Abort stops retrying; the caller receives the last response/error.
+	Abort RetryDecision = iota
+	// Retry resends the same request, unmodified, after a backoff.
+	Retry
+	// RetryAfterReauth re-authenticates the session (and, for a request
+	// carrying a lockHandle, re-acquires the lock) before resending.
+	RetryAfterReauth
+)
+
+// RetryPolicy configures the retry/backoff behavior installed by
+// WithRetry. The zero value is not usable; start from DefaultRetryPolicy.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Classifier decides what to do with a completed attempt. err is the
+	// transport-level error (nil if the request round-tripped); resp is
+	// nil when err is non-nil.
+	Classifier func(req *http.Request, resp *http.Response, err error) RetryDecision
+
+	// Relock re-acquires a lock for a lock-bound request whose handle was
+	// invalidated by a RetryAfterReauth cycle, returning the fresh handle
+	// to re-thread into the retried request. Lock-bound requests without
+	// a Relock hook are not retried after reauth: the original caller
+	// holds the only reference to the old handle and would silently
+	// operate on the wrong lock otherwise.
+	Relock RelockFunc
+
+	// OnRetry, if set, is called before each retried attempt so tests can
+	// assert how many attempts were made and why.
+	OnRetry func(attempt int, decision RetryDecision, err error)
+}
+
+// RelockFunc re-acquires MODIFY access to objectURL, returning the new
+// lock handle to substitute into a retried request.
+type RelockFunc func(ctx context.Context, objectURL string) (lockHandle string, err error)
+
+// reauthenticator is the subset of *Client that retryRoundTripper needs to
+// recover a dropped stateful session. Narrowing it to an interface (rather
+// than depending on *Client directly) lets tests drive RetryAfterReauth
+// without a live SAP backend.
+type reauthenticator interface {
+	reauthenticate(ctx context.Context) error
+}
+
+// DefaultRetryPolicy returns the policy WithRetry uses when none is given
+// explicitly: up to 4 attempts, exponential backoff from 250ms to 8s, and
+// DefaultRetryClassifier.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     8 * time.Second,
+		Multiplier:     2,
+		Classifier:     DefaultRetryClassifier,
+	}
+}
+
+// DefaultRetryClassifier retries network timeouts, unexpected EOFs (both
+// common when a long-running RunQuery/RunUnitTests call outlives a SAP
+// work process) and HTTP 5xx without reauthenticating. A 403 with
+// x-csrf-token: Required means the stateful session's token expired; a 401
+// means the session itself expired; both require RetryAfterReauth. Any
+// other 4xx is not retried: it reflects a request the server will keep
+// rejecting.
+func DefaultRetryClassifier(req *http.Request, resp *http.Response, err error) RetryDecision {
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return Retry
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return Retry
+		}
+		return Abort
+	}
+
+	if resp == nil {
+		return Abort
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return RetryAfterReauth
+	case http.StatusForbidden:
+		if strings.EqualFold(resp.Header.Get("x-csrf-token"), "Required") {
+			return RetryAfterReauth
+		}
+		return Abort
+	}
+
+	if resp.StatusCode >= 500 {
+		return Retry
+	}
+	return Abort
+}
+
+// WithRetry installs a RoundTripper that retries transient ADT failures
+// according to policy. Pass DefaultRetryPolicy() to start from the
+// client's default classifier and tune individual fields.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = &retryRoundTripper{
+			next:   c.httpClient.Transport,
+			client: c,
+			policy: policy,
+		}
+	}
+}
+
+type retryRoundTripper struct {
+	next   http.RoundTripper
+	client reauthenticator
+	policy RetryPolicy
+}
+
+func (rt *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := rt.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	_, isLockBound := lockHandleOf(req)
+	attemptReq := req
+	var lastErr error
+
+	for attempt := 1; attempt <= rt.policy.MaxAttempts; attempt++ {
+		body, bodyBytes, err := drainBody(attemptReq.Body)
+		if err != nil {
+			return nil, err
+		}
+		attemptReq.Body = body
+
+		resp, err := next.RoundTrip(attemptReq)
+		decision := rt.policy.Classifier(attemptReq, resp, err)
+
+		if decision == Abort || attempt == rt.policy.MaxAttempts {
+			return resp, err
+		}
+
+		lastErr = err
+		if rt.policy.OnRetry != nil {
+			rt.policy.OnRetry(attempt, decision, err)
+		}
+
+		nextReq := attemptReq.Clone(attemptReq.Context())
+		if bodyBytes != nil {
+			nextReq.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		}
+
+		if decision == RetryAfterReauth {
+			// A lock-bound write (UpdateSource, Activate, DeleteObject)
+			// can't simply be replayed after reauth: its lockHandle was
+			// minted under the now-dropped session and the original
+			// caller holds the only reference to it. Without a Relock
+			// hook we cannot safely re-acquire the lock, so give up
+			// rather than silently operate on a stale handle.
+			if isLockBound && rt.policy.Relock == nil {
+				return resp, lastErr
+			}
+			// Re-authenticate before re-acquiring the lock: Relock's own
+			// LockObject call needs the fresh session to succeed.
+			if err := rt.client.reauthenticate(req.Context()); err != nil {
+				return resp, err
+			}
+			if isLockBound {
+				newHandle, err := rt.policy.Relock(req.Context(), objectURLOf(req))
+				if err != nil {
+					return resp, err
+				}
+				nextReq = withLockHandle(nextReq, newHandle)
+			}
+		}
+
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+
+		if err := rt.sleepBackoff(attemptReq.Context(), attempt); err != nil {
+			return nil, err
+		}
+
+		attemptReq = nextReq
+	}
+
+	return nil, lastErr
+}
+
+func (rt *retryRoundTripper) sleepBackoff(ctx context.Context, attempt int) error {
+	backoff := rt.policy.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		backoff = time.Duration(float64(backoff) * rt.policy.Multiplier)
+	}
+	if backoff > rt.policy.MaxBackoff {
+		backoff = rt.policy.MaxBackoff
+	}
+	t := time.NewTimer(backoff)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// lockHandleOf reports the lockHandle query parameter on req, if any. It
+// identifies the lock-bound write calls (UpdateSource, Activate,
+// DeleteObject, UnlockObject) that must not be blindly retried with a
+// handle invalidated by a reauth cycle.
+func lockHandleOf(req *http.Request) (string, bool) {
+	handle := req.URL.Query().Get("lockHandle")
+	return handle, handle != ""
+}
+
+// objectURLOf strips the query string and any /source/main suffix from
+// req's URL, recovering the object URL LockObject was originally called
+// with.
+func objectURLOf(req *http.Request) string {
+	u := *req.URL
+	u.RawQuery = ""
+	return strings.TrimSuffix(u.Path, "/source/main")
+}
+
+// withLockHandle returns a shallow clone of req with its lockHandle query
+// parameter replaced by handle.
+func withLockHandle(req *http.Request, handle string) *http.Request {
+	q := req.URL.Query()
+	q.Set("lockHandle", handle)
+	u := *req.URL
+	u.RawQuery = q.Encode()
+	req.URL = &u
+	return req
+}
+
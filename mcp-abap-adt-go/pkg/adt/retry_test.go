@@ -0,0 +1,313 @@
+package adt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultRetryClassifier(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want RetryDecision
+	}{
+		{"timeout error", nil, timeoutError{}, Retry},
+		{"unexpected EOF", nil, io.ErrUnexpectedEOF, Retry},
+		{"other transport error", nil, errors.New("connection refused"), Abort},
+		{"503", &http.Response{StatusCode: 503, Header: http.Header{}}, nil, Retry},
+		{"401", &http.Response{StatusCode: 401, Header: http.Header{}}, nil, RetryAfterReauth},
+		{"403 csrf required", &http.Response{StatusCode: 403, Header: http.Header{"X-Csrf-Token": {"Required"}}}, nil, RetryAfterReauth},
+		{"403 other", &http.Response{StatusCode: 403, Header: http.Header{}}, nil, Abort},
+		{"404", &http.Response{StatusCode: 404, Header: http.Header{}}, nil, Abort},
+		{"200", &http.Response{StatusCode: 200, Header: http.Header{}}, nil, Abort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultRetryClassifier(&http.Request{}, tt.resp, tt.err); got != tt.want {
+				t.Errorf("DefaultRetryClassifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// flakyTransport fails with a 503 for the first failCount calls, then
+// succeeds with a 200.
+type flakyTransport struct {
+	failCount int
+	calls     int
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls++
+	if f.calls <= f.failCount {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: http.NoBody}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+}
+
+func TestRetryRoundTripperRetriesTransientFailures(t *testing.T) {
+	transport := &flakyTransport{failCount: 2}
+	var attempts []RetryDecision
+
+	rt := &retryRoundTripper{
+		next: transport,
+		policy: RetryPolicy{
+			MaxAttempts:    4,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+			Multiplier:     1,
+			Classifier:     DefaultRetryClassifier,
+			OnRetry: func(attempt int, decision RetryDecision, err error) {
+				attempts = append(attempts, decision)
+			},
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/sap/bc/adt/programs/programs/ZFOO", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want 200", resp.StatusCode)
+	}
+	if transport.calls != 3 {
+		t.Errorf("calls = %d, want 3 (2 failures + 1 success)", transport.calls)
+	}
+	if len(attempts) != 2 {
+		t.Errorf("OnRetry invoked %d times, want 2", len(attempts))
+	}
+}
+
+func TestRetryRoundTripperGivesUpAfterMaxAttempts(t *testing.T) {
+	transport := &flakyTransport{failCount: 10}
+
+	rt := &retryRoundTripper{
+		next: transport,
+		policy: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+			Multiplier:     1,
+			Classifier:     DefaultRetryClassifier,
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/sap/bc/adt/programs/programs/ZFOO", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("StatusCode = %d, want 503 (exhausted retries)", resp.StatusCode)
+	}
+	if transport.calls != 3 {
+		t.Errorf("calls = %d, want 3 (MaxAttempts)", transport.calls)
+	}
+}
+
+func TestLockHandleOf(t *testing.T) {
+	u, _ := url.Parse("https://example.com/sap/bc/adt/programs/programs/ZFOO?_action=UNLOCK&lockHandle=abc123")
+	handle, ok := lockHandleOf(&http.Request{URL: u})
+	if !ok || handle != "abc123" {
+		t.Errorf("lockHandleOf() = (%q, %v), want (\"abc123\", true)", handle, ok)
+	}
+
+	u2, _ := url.Parse("https://example.com/sap/bc/adt/programs/programs/ZFOO")
+	_, ok2 := lockHandleOf(&http.Request{URL: u2})
+	if ok2 {
+		t.Error("lockHandleOf() should report false when there is no lockHandle param")
+	}
+}
+
+// recordingReauthenticator counts reauthenticate calls and fails each one
+// with err, if set. A test's Relock hook can read calls to assert that
+// reauthenticate ran before Relock, not after.
+type recordingReauthenticator struct {
+	err   error
+	calls int
+}
+
+func (r *recordingReauthenticator) reauthenticate(ctx context.Context) error {
+	r.calls++
+	return r.err
+}
+
+// unauthorizedThenOKTransport returns a 401 (with a body the caller should
+// still be able to read if the request is ultimately aborted) for the
+// first failCount calls, then a 200, recording the lockHandle query
+// parameter it saw on each call.
+type unauthorizedThenOKTransport struct {
+	failCount   int
+	calls       int
+	lockHandles []string
+}
+
+func (f *unauthorizedThenOKTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls++
+	handle, _ := lockHandleOf(req)
+	f.lockHandles = append(f.lockHandles, handle)
+	if f.calls <= f.failCount {
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("csrf session expired")),
+		}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+}
+
+func lockBoundRequest(t *testing.T, lockHandle string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/sap/bc/adt/programs/programs/ZFOO/source/main?lockHandle="+lockHandle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestRetryRoundTripperReauthenticatesThenRelocksLockBoundRequest(t *testing.T) {
+	transport := &unauthorizedThenOKTransport{failCount: 1}
+	auth := &recordingReauthenticator{}
+	var relockCalls int
+	var relockSawAuthCalls int
+
+	rt := &retryRoundTripper{
+		next:   transport,
+		client: auth,
+		policy: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+			Multiplier:     1,
+			Classifier:     DefaultRetryClassifier,
+			Relock: func(ctx context.Context, objectURL string) (string, error) {
+				relockCalls++
+				relockSawAuthCalls = auth.calls
+				if objectURL != "/sap/bc/adt/programs/programs/ZFOO" {
+					t.Errorf("Relock objectURL = %q, want the object URL without /source/main or query", objectURL)
+				}
+				return "fresh-handle", nil
+			},
+		},
+	}
+
+	resp, err := rt.RoundTrip(lockBoundRequest(t, "stale-handle"))
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want 200", resp.StatusCode)
+	}
+	if auth.calls != 1 {
+		t.Errorf("reauthenticate called %d times, want 1", auth.calls)
+	}
+	if relockCalls != 1 {
+		t.Errorf("Relock called %d times, want 1", relockCalls)
+	}
+	if relockSawAuthCalls != 1 {
+		t.Error("Relock ran before reauthenticate completed; want reauthenticate first so the relock call is authenticated")
+	}
+	if got := transport.lockHandles; len(got) != 2 || got[1] != "fresh-handle" {
+		t.Errorf("lockHandles seen by transport = %v, want the retried request to carry \"fresh-handle\"", got)
+	}
+}
+
+func TestRetryRoundTripperAbortsLockBoundReauthWithoutRelock(t *testing.T) {
+	transport := &unauthorizedThenOKTransport{failCount: 10}
+	auth := &recordingReauthenticator{}
+
+	rt := &retryRoundTripper{
+		next:   transport,
+		client: auth,
+		policy: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+			Multiplier:     1,
+			Classifier:     DefaultRetryClassifier,
+		},
+	}
+
+	resp, err := rt.RoundTrip(lockBoundRequest(t, "stale-handle"))
+	if err != nil {
+		t.Fatalf("RoundTrip returned error %v, want the unauthorized response with nil error", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("StatusCode = %d, want 401", resp.StatusCode)
+	}
+	if auth.calls != 0 {
+		t.Errorf("reauthenticate called %d times, want 0 (no Relock hook configured)", auth.calls)
+	}
+	if transport.calls != 1 {
+		t.Errorf("transport called %d times, want 1 (must not retry a lock-bound write with no way to relock)", transport.calls)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "csrf session expired" {
+		t.Errorf("resp.Body = (%q, %v), want the original response body still readable, not closed", body, err)
+	}
+}
+
+func TestRetryRoundTripperReauthenticateErrorLeavesBodyReadable(t *testing.T) {
+	transport := &unauthorizedThenOKTransport{failCount: 10}
+	auth := &recordingReauthenticator{err: errors.New("reauth failed")}
+
+	rt := &retryRoundTripper{
+		next:   transport,
+		client: auth,
+		policy: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+			Multiplier:     1,
+			Classifier:     DefaultRetryClassifier,
+			Relock: func(ctx context.Context, objectURL string) (string, error) {
+				t.Error("Relock should not be called when reauthenticate itself fails")
+				return "", nil
+			},
+		},
+	}
+
+	resp, err := rt.RoundTrip(lockBoundRequest(t, "stale-handle"))
+	if err == nil || err.Error() != "reauth failed" {
+		t.Fatalf("RoundTrip error = %v, want the reauthenticate error", err)
+	}
+	body, readErr := io.ReadAll(resp.Body)
+	if readErr != nil || string(body) != "csrf session expired" {
+		t.Errorf("resp.Body = (%q, %v), want the original response body still readable, not closed", body, readErr)
+	}
+}
+
+func TestWithLockHandleRewritesQueryParam(t *testing.T) {
+	u, _ := url.Parse("https://example.com/sap/bc/adt/programs/programs/ZFOO?_action=UNLOCK&lockHandle=stale")
+	req := withLockHandle(&http.Request{URL: u}, "fresh")
+
+	if got, _ := lockHandleOf(req); got != "fresh" {
+		t.Errorf("lockHandle after rewrite = %q, want \"fresh\"", got)
+	}
+	if req.URL.Query().Get("_action") != "UNLOCK" {
+		t.Error("withLockHandle should not disturb other query parameters")
+	}
+}